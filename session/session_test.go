@@ -0,0 +1,84 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/mr-pmillz/gokrb5/v8/credentials"
+	"github.com/mr-pmillz/gokrb5/v8/iana/nametype"
+	"github.com/mr-pmillz/gokrb5/v8/keytab"
+	"github.com/mr-pmillz/gokrb5/v8/messages"
+	"github.com/mr-pmillz/gokrb5/v8/types"
+)
+
+// TestSaveCCacheRoundTrip builds an ASRep the way a real AS-REP would
+// populate it and checks that saveCCache's hand-encoded bytes are readable
+// back via the real credentials.LoadCCache, catching layout bugs (e.g. a
+// missing principal block) that would otherwise only surface against
+// third-party tools consuming the ccache.
+func TestSaveCCacheRoundTrip(t *testing.T) {
+	const realm = "EXAMPLE.COM"
+	const username = "bob"
+
+	sktab := keytab.New()
+	if err := sktab.AddEntry("krbtgt/"+realm, realm, "password", time.Now(), 1, 23); err != nil {
+		t.Fatalf("couldn't build service keytab: %s", err)
+	}
+
+	cname := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, username)
+	sname := types.NewPrincipalName(nametype.KRB_NT_SRV_INST, "krbtgt/"+realm)
+	now := time.Now().Round(time.Second)
+	flags := asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32}
+
+	tkt, sessionKey, err := messages.NewTicket(cname, realm, sname, realm, flags, sktab, 23, 1, now, now, now.Add(time.Hour), now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("couldn't build ticket: %s", err)
+	}
+
+	asRep := messages.ASRep{
+		KDCRepFields: messages.KDCRepFields{
+			CName:  cname,
+			CRealm: realm,
+			Ticket: tkt,
+			DecryptedEncPart: messages.EncKDCRepPart{
+				Key:       sessionKey,
+				Flags:     flags,
+				AuthTime:  now,
+				StartTime: now,
+				EndTime:   now.Add(time.Hour),
+				RenewTill: now.Add(2 * time.Hour),
+				SRealm:    realm,
+				SName:     sname,
+			},
+		},
+	}
+
+	k := KerbruteSession{Realm: realm, CcacheDir: t.TempDir()}
+	if err := k.saveCCache(username, asRep); err != nil {
+		t.Fatalf("saveCCache failed: %s", err)
+	}
+
+	path := k.CcacheDir + "/" + username + "@" + realm + ".ccache"
+	cc, err := credentials.LoadCCache(path)
+	if err != nil {
+		t.Fatalf("credentials.LoadCCache couldn't read the ccache saveCCache wrote: %s", err)
+	}
+
+	if got := cc.DefaultPrincipal.PrincipalName.PrincipalNameString(); got != username {
+		t.Errorf("DefaultPrincipal = %q, want %q", got, username)
+	}
+	if len(cc.Credentials) != 1 {
+		t.Fatalf("got %d credentials, want 1", len(cc.Credentials))
+	}
+	cred := cc.Credentials[0]
+	if got := cred.Client.PrincipalName.PrincipalNameString(); got != username {
+		t.Errorf("Credential.Client = %q, want %q", got, username)
+	}
+	if got := cred.Server.PrincipalName.PrincipalNameString(); got != sname.PrincipalNameString() {
+		t.Errorf("Credential.Server = %q, want %q", got, sname.PrincipalNameString())
+	}
+	if cred.Key.KeyType != sessionKey.KeyType {
+		t.Errorf("Credential.Key.KeyType = %d, want %d", cred.Key.KeyType, sessionKey.KeyType)
+	}
+}
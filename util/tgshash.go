@@ -0,0 +1,28 @@
+package util
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mr-pmillz/gokrb5/v8/messages"
+	"github.com/mr-pmillz/gokrb5/v8/types"
+)
+
+// TicketToHashcat formats a service ticket's encrypted portion as a
+// hashcat-crackable $krb5tgs$ string, mirroring ASRepToHashcat's layout for
+// AS-REPs. cname/realm identify the client the ticket was obtained for,
+// since a bare messages.Ticket doesn't carry them.
+func TicketToHashcat(cname types.PrincipalName, realm string, tkt messages.Ticket) (string, error) {
+	cipher := tkt.EncPart.Cipher
+	if len(cipher) < 16 {
+		return "", fmt.Errorf("ciphertext too short to be a valid service ticket")
+	}
+	return fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%s$%s",
+		tkt.EncPart.EType,
+		cname.PrincipalNameString(),
+		realm,
+		tkt.SName.PrincipalNameString(),
+		hex.EncodeToString(cipher[:16]),
+		hex.EncodeToString(cipher[16:]),
+	), nil
+}